@@ -0,0 +1,214 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/PullRequestInc/difftools/pkg/models"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Backend performs the content-matching half of a search: given a revision
+// and a candidate set of files, it returns a SearchResult per matching line.
+// GitGrepBackend shells out to git grep; GoGitGrepBackend walks blobs
+// in-process via go-git, for repos on the local filesystem where forking git
+// per request isn't viable.
+type Backend interface {
+	GrepFiles(ctx context.Context, repoDir, ref string, files []string, query string, caseSensitive, regex, isFuzzy, includeBinary bool, contextLines, matchesPerFile int, includeGlobs, excludeGlobs []string, parser grepParser) ([]*models.SearchResult, int, error)
+}
+
+// GitGrepBackend is the default Backend. It shells out to git grep in a
+// local repoDir; searching the working tree (an empty ref) requires a
+// non-bare working copy, while a non-empty ref is searched as a tree-ish.
+type GitGrepBackend struct{}
+
+// GrepFiles implements Backend by delegating to the package's git-grep
+// invocation.
+func (GitGrepBackend) GrepFiles(ctx context.Context, repoDir, ref string, files []string, query string, caseSensitive, regex, isFuzzy, includeBinary bool, contextLines, matchesPerFile int, includeGlobs, excludeGlobs []string, parser grepParser) ([]*models.SearchResult, int, error) {
+	return grepFiles(ctx, repoDir, ref, files, query, caseSensitive, regex, isFuzzy, includeBinary, contextLines, matchesPerFile, includeGlobs, excludeGlobs, parser)
+}
+
+// GoGitGrepBackend is a pure-Go Backend that opens repoDir with go-git and
+// matches query against blob contents directly from the object store. It
+// never forks git or requires a checkout, so it works against bare repos as
+// well as ones with a working tree. The repo is always opened by its path on
+// the local filesystem; remote and in-memory repos are not supported.
+type GoGitGrepBackend struct{}
+
+// GrepFiles implements Backend by walking the tree at ref (HEAD if ref is
+// empty) and matching query against each candidate blob's contents.
+func (GoGitGrepBackend) GrepFiles(ctx context.Context, repoDir, ref string, files []string, query string, caseSensitive, regex, isFuzzy, includeBinary bool, contextLines, matchesPerFile int, includeGlobs, excludeGlobs []string, parser grepParser) ([]*models.SearchResult, int, error) {
+	if len(files) == 0 {
+		return nil, 0, nil
+	}
+	if regex && isFuzzy {
+		return nil, 0, fmt.Errorf("regex and fuzzy search modes are mutually exclusive")
+	}
+	if contextLines > 0 {
+		// Unlike GitGrepBackend, which gets context lines for free from git
+		// grep -C, this backend reads blobs directly and doesn't yet gather
+		// surrounding lines. Fail loudly rather than silently returning
+		// results without the requested context.
+		return nil, 0, fmt.Errorf("GoGitGrepBackend does not support ContextLines > 0")
+	}
+
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open repo %q: %w", repoDir, err)
+	}
+
+	revision := ref
+	if revision == "" {
+		revision = "HEAD"
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to resolve revision %q: %w", revision, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load commit for revision %q: %w", revision, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load tree for revision %q: %w", revision, err)
+	}
+
+	wanted := make(map[string]bool, len(files))
+	for _, file := range files {
+		wanted[file] = true
+	}
+
+	var tokens []string
+	var queryRE *regexp.Regexp
+	if isFuzzy {
+		tokens = strings.Fields(query)
+	} else if regex {
+		pattern := query
+		if !caseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		if queryRE, err = regexp.Compile(pattern); err != nil {
+			return nil, 0, fmt.Errorf("failed to compile regex %q: %w", query, err)
+		}
+	}
+
+	var results []*models.SearchResult
+	numMatches := 0
+	matchesInFile := 0
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for numMatches < parser.limit {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to walk tree for revision %q: %w", revision, err)
+		}
+		if !entry.Mode.IsFile() || !wanted[name] {
+			continue
+		}
+		if len(includeGlobs) > 0 && !matchesAnyGlob(name, includeGlobs) {
+			continue
+		}
+		if matchesAnyGlob(name, excludeGlobs) {
+			continue
+		}
+
+		content, err := readBlob(repo, entry.Hash)
+		if err != nil {
+			continue
+		}
+		if !includeBinary && bytes.IndexByte(truncate(content, 8000), 0) != -1 {
+			continue
+		}
+
+		matchesInFile = 0
+		for lineIdx, line := range strings.Split(string(content), "\n") {
+			if numMatches >= parser.limit {
+				break
+			}
+			if matchesPerFile > 0 && matchesInFile >= matchesPerFile {
+				break
+			}
+			if len(line) > snippetLineLengthMax {
+				continue
+			}
+
+			if !lineMatches(line, query, tokens, caseSensitive, regex, isFuzzy, queryRE) {
+				continue
+			}
+
+			// No git-computed column to fall back on here: this backend
+			// matches lines with the same Go regexp used for highlighting,
+			// so there's no cross-engine mismatch to guard against.
+			ranges := findMatchRanges(line, query, tokens, caseSensitive, regex, isFuzzy, 0)
+			results = append(results, &models.SearchResult{
+				Path:              name,
+				LineNumber:        lineIdx + 1,
+				Snippet:           highlightSnippet(line, ranges),
+				HighlightedRanges: ranges,
+			})
+			numMatches++
+			matchesInFile++
+		}
+	}
+
+	if isFuzzy {
+		results = rankByTokenMatches(results, tokens, caseSensitive)
+	}
+
+	return results, numMatches, nil
+}
+
+// lineMatches reports whether line matches query under the given search
+// mode, mirroring grepFiles' own mode handling: fuzzy tokens and plain
+// queries are matched as literals, and regex mode uses Go's regexp, so a
+// regex that git's engine and Go's regexp treat differently can match
+// differently in the two backends.
+func lineMatches(line, query string, tokens []string, caseSensitive, regex, isFuzzy bool, queryRE *regexp.Regexp) bool {
+	switch {
+	case isFuzzy:
+		for _, token := range tokens {
+			if len(findAllLiteral(line, token, caseSensitive)) > 0 {
+				return true
+			}
+		}
+		return false
+	case regex:
+		return queryRE.MatchString(line)
+	default:
+		return len(findAllLiteral(line, query, caseSensitive)) > 0
+	}
+}
+
+// readBlob returns the full contents of the blob with the given hash.
+func readBlob(repo *git.Repository, hash plumbing.Hash) ([]byte, error) {
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// truncate returns the first n bytes of b, or all of b if it's shorter.
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+)
+
+// filterByGlobs returns the subset of files that match at least one pattern
+// in includeGlobs (or all files, if includeGlobs is empty) and none of the
+// patterns in excludeGlobs.
+func filterByGlobs(files []string, includeGlobs, excludeGlobs []string) []string {
+	if len(includeGlobs) == 0 && len(excludeGlobs) == 0 {
+		return files
+	}
+
+	var filtered []string
+	for _, path := range files {
+		if len(includeGlobs) > 0 && !matchesAnyGlob(path, includeGlobs) {
+			continue
+		}
+		if matchesAnyGlob(path, excludeGlobs) {
+			continue
+		}
+		filtered = append(filtered, path)
+	}
+	return filtered
+}
+
+// matchesAnyGlob reports whether path matches any bash-style glob pattern in
+// patterns, e.g. "*.go", "vendor/**" or "{foo,bar}/*.js".
+func matchesAnyGlob(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		for _, expanded := range expandBraces(pattern) {
+			if globToRegexp(expanded).MatchString(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandBraces expands a single {a,b,c} alternation in pattern into one
+// pattern per alternative. Only one level of braces is supported, which
+// covers the glob patterns this package's callers use.
+func expandBraces(pattern string) []string {
+	start := strings.Index(pattern, "{")
+	end := strings.Index(pattern, "}")
+	if start == -1 || end == -1 || end < start {
+		return []string{pattern}
+	}
+
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	alternatives := strings.Split(pattern[start+1:end], ",")
+
+	expanded := make([]string, 0, len(alternatives))
+	for _, alt := range alternatives {
+		expanded = append(expanded, prefix+alt+suffix)
+	}
+	return expanded
+}
+
+// globToRegexp compiles a bash-style glob pattern into an anchored regexp.
+// "**" matches any sequence of characters, including "/"; "*" matches any
+// sequence excluding "/"; "?" matches a single non-"/" character.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var out strings.Builder
+	out.WriteString("^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				out.WriteString(".*")
+				i++
+			} else {
+				out.WriteString("[^/]*")
+			}
+		case '?':
+			out.WriteString("[^/]")
+		default:
+			out.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+
+	out.WriteString("$")
+	return regexp.MustCompile(out.String())
+}
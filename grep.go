@@ -0,0 +1,324 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PullRequestInc/difftools/pkg/models"
+)
+
+// grepParser accumulates SearchResults parsed from git-grep output, stopping
+// once limit matches have been collected.
+type grepParser struct {
+	limit         int
+	query         string
+	caseSensitive bool
+	regex         bool
+	isFuzzy       bool
+}
+
+// newGrepParser constructs a grepParser that stops after limit matches.
+func newGrepParser(limit int) grepParser {
+	return grepParser{limit: limit}
+}
+
+// grepFiles runs git grep for query over files in repoDir and returns the
+// resulting SearchResults along with the number of matching lines found. If
+// ref is non-empty, the search runs against that tree-ish (via "git grep
+// <ref>") instead of the working tree, so callers can search a revision
+// without checking it out.
+func grepFiles(ctx context.Context, repoDir, ref string, files []string, query string, caseSensitive, regex, isFuzzy, includeBinary bool, contextLines, matchesPerFile int, includeGlobs, excludeGlobs []string, parser grepParser) ([]*models.SearchResult, int, error) {
+	if len(files) == 0 {
+		return nil, 0, nil
+	}
+
+	if regex && isFuzzy {
+		return nil, 0, fmt.Errorf("regex and fuzzy search modes are mutually exclusive")
+	}
+
+	args := []string{"grep", "--null", "-n", "--column"}
+	if !includeBinary {
+		// -I skips files git considers binary, so we never waste work
+		// producing snippets from them.
+		args = append(args, "-I")
+	}
+	if !caseSensitive {
+		args = append(args, "-i")
+	}
+	if contextLines > 0 {
+		args = append(args, "-C", strconv.Itoa(contextLines))
+	}
+	if matchesPerFile > 0 {
+		// Caps matches per file so one huge file can't consume the entire
+		// Limit and hide matches in every other file.
+		args = append(args, "--max-count", strconv.Itoa(matchesPerFile))
+	}
+
+	var tokens []string
+	switch {
+	case isFuzzy:
+		tokens = strings.Fields(query)
+		args = append(args, "-F")
+		for _, token := range tokens {
+			args = append(args, "-e", token)
+		}
+	case regex:
+		args = append(args, "-E", "-e", query)
+	default:
+		args = append(args, "-F", "-e", query)
+	}
+
+	if ref != "" {
+		args = append(args, ref)
+	}
+	args = append(args, "--")
+	args = append(args, files...)
+	for _, glob := range includeGlobs {
+		args = append(args, ":(glob)"+glob)
+	}
+	for _, glob := range excludeGlobs {
+		args = append(args, ":(exclude,glob)"+glob)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			// Exit code 1 means no matches were found.
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to run git grep in dir %q: %v: %s", repoDir, err, stderr.String())
+	}
+
+	parser.query = query
+	parser.caseSensitive = caseSensitive
+	parser.regex = regex
+	parser.isFuzzy = isFuzzy
+
+	results, numMatches, err := parser.parse(&stdout, tokens)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse git grep output in dir %q: %w", repoDir, err)
+	}
+	if isFuzzy {
+		results = rankByTokenMatches(results, tokens, caseSensitive)
+	}
+	return results, numMatches, nil
+}
+
+// rankByTokenMatches reorders results so that files whose matched lines
+// collectively contain more of tokens come first, preserving relative order
+// among files with the same count.
+func rankByTokenMatches(results []*models.SearchResult, tokens []string, caseSensitive bool) []*models.SearchResult {
+	fileTokenCounts := make(map[string]map[string]bool)
+	for _, result := range results {
+		matched, ok := fileTokenCounts[result.Path]
+		if !ok {
+			matched = make(map[string]bool)
+			fileTokenCounts[result.Path] = matched
+		}
+
+		compareContent := result.Snippet
+		if !caseSensitive {
+			compareContent = strings.ToLower(compareContent)
+		}
+		for _, token := range tokens {
+			compareToken := token
+			if !caseSensitive {
+				compareToken = strings.ToLower(compareToken)
+			}
+			if strings.Contains(compareContent, compareToken) {
+				matched[token] = true
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return len(fileTokenCounts[results[i].Path]) > len(fileTokenCounts[results[j].Path])
+	})
+	return results
+}
+
+// parse reads git-grep output produced with --null -n --column. With
+// --null, every field separator is NUL, not just the one after the
+// filename: a matched line is "path\0lineno\0col\0content" (4 fields),
+// while a context line pulled in by -C has no column and is
+// "path\0lineno\0content" (3 fields). parse branches on the field count to
+// tell the two apart, returning a SearchResult for each and stopping once
+// p.limit matches (context lines don't count against the limit) are found.
+// The scan buffer is sized well beyond snippetLineLengthMax so a single long
+// raw line (e.g. in a minified file) doesn't abort the scan before it's
+// filtered out by the length check below; parse still surfaces scanner.Err()
+// so a line longer than that buffer is reported rather than silently
+// truncating the result set.
+func (p grepParser) parse(r *bytes.Buffer, tokens []string) ([]*models.SearchResult, int, error) {
+	var results []*models.SearchResult
+	numMatches := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if numMatches >= p.limit {
+			break
+		}
+
+		line := scanner.Text()
+		fields := strings.SplitN(line, "\x00", 4)
+
+		var path, lineNumStr, colStr, content string
+		isMatch := false
+		switch len(fields) {
+		case 4:
+			path, lineNumStr, colStr, content = fields[0], fields[1], fields[2], fields[3]
+			isMatch = true
+		case 3:
+			path, lineNumStr, content = fields[0], fields[1], fields[2]
+		default:
+			continue
+		}
+
+		lineNum, err := strconv.Atoi(lineNumStr)
+		if err != nil {
+			continue
+		}
+
+		if len(content) > snippetLineLengthMax {
+			continue
+		}
+
+		var ranges [][3]int
+		if isMatch {
+			// git-grep's column is 1-based and only locates the first match
+			// on the line; pass it through as a fallback anchor in case the
+			// regex that matched under git's engine can't be reproduced by
+			// Go's regexp package.
+			col, _ := strconv.Atoi(colStr)
+			ranges = findMatchRanges(content, p.query, tokens, p.caseSensitive, p.regex, p.isFuzzy, col)
+		}
+		results = append(results, &models.SearchResult{
+			Path:              path,
+			LineNumber:        lineNum,
+			Snippet:           highlightSnippet(content, ranges),
+			HighlightedRanges: ranges,
+		})
+		if isMatch {
+			numMatches++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return results, numMatches, fmt.Errorf("failed to scan git grep output: %w", err)
+	}
+
+	return results, numMatches, nil
+}
+
+// findMatchRanges locates every non-overlapping match of query (or, in fuzzy
+// mode, of each token) within content and returns them as [lineIndex,
+// startCol, endCol] triples. Since each SearchResult here covers a single
+// line, lineIndex is always 0.
+//
+// gitColumn is the 1-based column git-grep itself reported for the line's
+// first match, or 0 if unavailable. In regex mode it's used as a fallback:
+// git matched this line using its own regex engine, which doesn't always
+// agree with Go's regexp (RE2) package, so if the pattern fails to compile
+// or produces no match here, gitColumn still lets us highlight the position
+// git found instead of returning no ranges at all.
+func findMatchRanges(content, query string, tokens []string, caseSensitive, regex, isFuzzy bool, gitColumn int) [][3]int {
+	var positions [][2]int
+
+	switch {
+	case isFuzzy:
+		for _, token := range tokens {
+			positions = append(positions, findAllLiteral(content, token, caseSensitive)...)
+		}
+	case regex:
+		pattern := query
+		if !caseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err == nil {
+			for _, match := range re.FindAllStringIndex(content, -1) {
+				positions = append(positions, [2]int{match[0], match[1]})
+			}
+		}
+		if len(positions) == 0 && gitColumn > 0 {
+			start := gitColumn - 1
+			if start >= 0 && start < len(content) {
+				positions = append(positions, [2]int{start, start + 1})
+			}
+		}
+	default:
+		positions = append(positions, findAllLiteral(content, query, caseSensitive)...)
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i][0] < positions[j][0] })
+
+	ranges := make([][3]int, 0, len(positions))
+	for _, pos := range positions {
+		ranges = append(ranges, [3]int{0, pos[0], pos[1]})
+	}
+	return ranges
+}
+
+// findAllLiteral returns the start/end byte offsets of every non-overlapping
+// occurrence of substr in s, honoring caseSensitive.
+func findAllLiteral(s, substr string, caseSensitive bool) [][2]int {
+	if substr == "" {
+		return nil
+	}
+
+	compareS, compareSubstr := s, substr
+	if !caseSensitive {
+		compareS = strings.ToLower(s)
+		compareSubstr = strings.ToLower(substr)
+	}
+
+	var positions [][2]int
+	offset := 0
+	for {
+		idx := strings.Index(compareS[offset:], compareSubstr)
+		if idx == -1 {
+			break
+		}
+		start := offset + idx
+		end := start + len(substr)
+		positions = append(positions, [2]int{start, end})
+		offset = end
+	}
+	return positions
+}
+
+// highlightSnippet wraps each matched range in content with "**...**" so
+// results can be rendered without interpreting terminal control sequences or
+// relying on the caller reimplementing range-based highlighting.
+func highlightSnippet(content string, ranges [][3]int) string {
+	if len(ranges) == 0 {
+		return content
+	}
+
+	var out strings.Builder
+	prevEnd := 0
+	for _, rng := range ranges {
+		start, end := rng[1], rng[2]
+		if start < prevEnd {
+			continue
+		}
+		out.WriteString(content[prevEnd:start])
+		out.WriteString("**")
+		out.WriteString(content[start:end])
+		out.WriteString("**")
+		prevEnd = end
+	}
+	out.WriteString(content[prevEnd:])
+	return out.String()
+}
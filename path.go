@@ -0,0 +1,91 @@
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PullRequestInc/difftools/pkg/models"
+)
+
+// findPathMatches returns a SearchResult for each path in files whose name
+// matches query, up to limit results. In fuzzy mode query is split on
+// whitespace and a path matches if it contains any of the resulting tokens;
+// paths matching more tokens are ranked ahead of paths matching fewer.
+func findPathMatches(files []string, query string, caseSensitive, isFuzzy bool, queryRE *regexp.Regexp, limit int) ([]*models.SearchResult, error) {
+	if isFuzzy {
+		return findFuzzyPathMatches(files, query, caseSensitive, limit), nil
+	}
+
+	var results []*models.SearchResult
+	for _, path := range files {
+		if len(results) >= limit {
+			break
+		}
+
+		if queryRE != nil {
+			if queryRE.MatchString(path) {
+				results = append(results, &models.SearchResult{Path: path, Snippet: path})
+			}
+			continue
+		}
+
+		comparePath, compareQuery := path, query
+		if !caseSensitive {
+			comparePath = strings.ToLower(comparePath)
+			compareQuery = strings.ToLower(compareQuery)
+		}
+		if strings.Contains(comparePath, compareQuery) {
+			results = append(results, &models.SearchResult{Path: path, Snippet: path})
+		}
+	}
+	return results, nil
+}
+
+// findFuzzyPathMatches matches each whitespace-separated token in query
+// against files independently (OR semantics) and returns results ordered by
+// descending token-match count.
+func findFuzzyPathMatches(files []string, query string, caseSensitive bool, limit int) []*models.SearchResult {
+	tokens := strings.Fields(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	type scoredPath struct {
+		path  string
+		score int
+	}
+
+	var scored []scoredPath
+	for _, path := range files {
+		comparePath := path
+		if !caseSensitive {
+			comparePath = strings.ToLower(comparePath)
+		}
+
+		score := 0
+		for _, token := range tokens {
+			compareToken := token
+			if !caseSensitive {
+				compareToken = strings.ToLower(compareToken)
+			}
+			if strings.Contains(comparePath, compareToken) {
+				score++
+			}
+		}
+		if score > 0 {
+			scored = append(scored, scoredPath{path: path, score: score})
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+
+	var results []*models.SearchResult
+	for _, s := range scored {
+		if len(results) >= limit {
+			break
+		}
+		results = append(results, &models.SearchResult{Path: s.path, Snippet: s.path})
+	}
+	return results
+}
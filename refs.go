@@ -0,0 +1,123 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/PullRequestInc/difftools/pkg/models"
+)
+
+// RefSearchResult pairs a SearchResult with the ref (branch or tag) it was
+// found in, letting callers of QuerySearchRefs tell which revision matched.
+type RefSearchResult struct {
+	*models.SearchResult
+	Ref string
+}
+
+// QuerySearchRefs finds occurrences of 'query' in 'repoDir' content and/or
+// path across multiple revisions (branches or tags). Unlike QuerySearch, it
+// never checks out a revision: each ref is listed and grepped via its
+// tree-ish directly ("git grep <ref>"), so searching many refs of the same
+// clone is non-destructive and safe to run concurrently.
+//
+// opt.BaseSha is not supported here, since there is no single checked-out
+// revision to diff against.
+func QuerySearchRefs(ctx context.Context, repoDir string, refs []string, query string, opt Options) ([]*RefSearchResult, int, error) {
+	if opt.Regex && opt.IsFuzzy {
+		return nil, 0, fmt.Errorf("regex and fuzzy search modes are mutually exclusive")
+	}
+
+	var results []*RefSearchResult
+	numMatches := 0
+	for _, ref := range refs {
+		if numMatches >= opt.Limit {
+			break
+		}
+
+		refResults, refNumMatches, err := querySearchRef(ctx, repoDir, ref, query, opt, opt.Limit-numMatches)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to search ref %q: %w", ref, err)
+		}
+
+		for _, result := range refResults {
+			results = append(results, &RefSearchResult{SearchResult: result, Ref: ref})
+		}
+		numMatches += refNumMatches
+	}
+
+	return results, numMatches, nil
+}
+
+// querySearchRef runs QuerySearch's file-listing and matching logic for a
+// single ref, without checking it out. Because there is no working tree to
+// stat, the symlink filtering QuerySearch applies after its checkout is
+// skipped here; path and content matches are otherwise filtered identically.
+func querySearchRef(ctx context.Context, repoDir, ref, query string, opt Options, limit int) ([]*models.SearchResult, int, error) {
+	var results []*models.SearchResult
+	numMatches := 0
+
+	unfilteredFiles, err := opt.Git.ListFiles(ctx, repoDir, ref)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var filteredFiles []string
+	for _, path := range unfilteredFiles {
+		// Skip paths equal to "--". git-grep's output is ambiguous if we allow
+		// files with this name.
+		if path == "--" {
+			continue
+		}
+
+		// Apply user filter, if any.
+		if opt.FileFilter != nil {
+			if allow, err := opt.FileFilter(path); err != nil {
+				return nil, 0, fmt.Errorf("failed to evaluate file %q: %v", path, err)
+			} else if !allow {
+				continue
+			}
+		}
+
+		filteredFiles = append(filteredFiles, path)
+	}
+
+	// Search through filenames for matches. There's no git invocation behind
+	// findPathMatches to hand pathspec globs to, so IncludeGlobs/ExcludeGlobs
+	// are applied here in Go.
+	if opt.SearchPath {
+		pathSearchFiles := filterByGlobs(filteredFiles, opt.IncludeGlobs, opt.ExcludeGlobs)
+
+		var queryRE *regexp.Regexp
+		if opt.Regex {
+			queryRE, err = regexp.Compile(query)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to query path names due to regexp issue: %v", err)
+			}
+		}
+		pathResults, err := findPathMatches(pathSearchFiles, query, opt.CaseSensitive, opt.IsFuzzy, queryRE, limit)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to query path names in dir %q: %v", repoDir, err)
+		}
+		results = append(results, pathResults...)
+		numMatches = numMatches + len(pathResults)
+	}
+
+	// Search through contents for matches. IncludeGlobs/ExcludeGlobs are
+	// applied here in Go: git pathspecs OR together, so an include glob
+	// can't narrow a search that already lists files explicitly. See
+	// QuerySearch's equivalent comment for the full rationale.
+	if opt.SearchContent && numMatches < limit {
+		contentSearchFiles := filterByGlobs(filteredFiles, opt.IncludeGlobs, opt.ExcludeGlobs)
+
+		parser := newGrepParser(limit - numMatches)
+		lineResults, numMatchLines, err := opt.Backend.GrepFiles(ctx, repoDir, ref, contentSearchFiles, query, opt.CaseSensitive, opt.Regex, opt.IsFuzzy, opt.IncludeBinary, opt.ContextLines, opt.MatchesPerFile, opt.IncludeGlobs, opt.ExcludeGlobs, parser)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to execute search in dir %q: %v", repoDir, err)
+		}
+		results = append(results, lineResults...)
+		numMatches = numMatches + numMatchLines
+	}
+
+	return results, numMatches, nil
+}
@@ -1,28 +1,17 @@
 package search
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"strconv"
-	"strings"
 
 	"github.com/PullRequestInc/difftools/pkg/models"
 	"github.com/PullRequestInc/difftools/pkg/runner"
 	"github.com/PullRequestInc/difftools/pkg/utils"
 )
 
-// Find text bookended by ANSI control sequences. git-grep highlights matches
-// red by default, but the control sequence spec allows several to express the
-// color red. Rather than try and parse the actual syntax, we just look for any
-// ANSI control sequence.
-var matchRE = regexp.MustCompile("(\u001b\\[.*?m)(.*?)(\u001b\\[.*?m)")
-
 // snippetLineLengthMax is the maximum number of characters allowed per line in
 // snippet lines. Matches that are after the line limit are not returned.
 const snippetLineLengthMax = 300
@@ -32,9 +21,11 @@ func NewOptions() Options {
 	var opt Options
 	opt.CaseSensitive = true
 	opt.Regex = false
+	opt.IncludeBinary = false
 	opt.Limit = 100
+	opt.MatchesPerFile = 20
 	opt.Git = runner.NewGit()
-	opt.GrepFiles = grepFiles
+	opt.Backend = GitGrepBackend{}
 	opt.SearchPath = true
 	opt.SearchContent = true
 	return opt
@@ -56,6 +47,11 @@ type Options struct {
 	// Maximum number of matches.
 	Limit int
 
+	// Maximum number of content matches returned per file, preventing one
+	// large file from consuming the entire Limit and hiding matches in every
+	// other file.
+	MatchesPerFile int
+
 	// Exempts files from the search if false is returned. Aborts the search if
 	// an error is returned.
 	FileFilter func(path string) (bool, error)
@@ -63,19 +59,48 @@ type Options struct {
 	// True if the search query should be treated as a regex.
 	Regex bool
 
+	// True if query should be split on whitespace into tokens that are
+	// matched independently (OR semantics), ranking files that match more
+	// tokens first. Mutually exclusive with Regex.
+	IsFuzzy bool
+
+	// True if binary files should be searched for content matches. Binary
+	// files never produce useful snippets and their control characters can
+	// confuse highlighting, so this defaults to false. Path-name matches
+	// against binary files are unaffected.
+	IncludeBinary bool
+
 	// Search for query in contents in files
 	SearchContent bool
 
 	// Search for query in names of files
 	SearchPath bool
 
+	// Bash-style glob patterns (e.g. "*.go", "vendor/**", "{foo,bar}/*.js")
+	// restricting the search to matching paths. If empty, all paths are
+	// considered.
+	IncludeGlobs []string
+
+	// Bash-style glob patterns excluding matching paths from the search,
+	// applied after IncludeGlobs.
+	ExcludeGlobs []string
+
+	// Backend performs the content-matching half of the search. Defaults to
+	// GitGrepBackend, which shells out to git grep; GoGitGrepBackend searches
+	// in-process via go-git for repos where forking git isn't viable, but
+	// rejects ContextLines > 0, which it doesn't yet support.
+	Backend Backend
+
 	// Overrides for testing.
-	Git       runner.Git
-	GrepFiles func(ctx context.Context, repoDir string, files []string, query string, caseSensitive bool, regex bool, contextLines int, parser grepParser) ([]*models.SearchResult, int, error)
+	Git runner.Git
 }
 
 // QuerySearch finds occurrences of 'query' in 'repoDir' content and/or path at 'sha' depending on type
 func QuerySearch(ctx context.Context, repoDir, sha, query string, opt Options) ([]*models.SearchResult, int, error) {
+	if opt.Regex && opt.IsFuzzy {
+		return nil, 0, fmt.Errorf("regex and fuzzy search modes are mutually exclusive")
+	}
+
 	var results []*models.SearchResult
 	var unfilteredFiles []string
 	var err error
@@ -86,8 +111,7 @@ func QuerySearch(ctx context.Context, repoDir, sha, query string, opt Options) (
 		unfilteredFiles, err = opt.Git.ListChangedFiles(ctx, repoDir, sha, opt.BaseSha)
 	}
 
-	if err != nil
-{
+	if err != nil {
 		return nil, 0, err
 	}
 
@@ -128,8 +152,12 @@ func QuerySearch(ctx context.Context, repoDir, sha, query string, opt Options) (
 		filteredFiles = append(filteredFiles, path)
 	}
 
-	// Search through filenames for matches
+	// Search through filenames for matches. There's no git invocation behind
+	// findPathMatches to hand pathspec globs to, so IncludeGlobs/ExcludeGlobs
+	// are applied here in Go.
 	if opt.SearchPath {
+		pathSearchFiles := filterByGlobs(filteredFiles, opt.IncludeGlobs, opt.ExcludeGlobs)
+
 		var queryRE *regexp.Regexp
 		if opt.Regex {
 			queryRE, err = regexp.Compile(query)
@@ -137,7 +165,7 @@ func QuerySearch(ctx context.Context, repoDir, sha, query string, opt Options) (
 				return nil, 0, fmt.Errorf("failed to query path names due to regexp issue: %v", err)
 			}
 		}
-		pathResults, err := findPathMatches(filteredFiles, query, opt.CaseSensitive, queryRE, opt.Limit)
+		pathResults, err := findPathMatches(pathSearchFiles, query, opt.CaseSensitive, opt.IsFuzzy, queryRE, opt.Limit)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to query path names in dir %q: %v", repoDir, err)
 		}
@@ -145,12 +173,18 @@ func QuerySearch(ctx context.Context, repoDir, sha, query string, opt Options) (
 		numMatches = numMatches + len(pathResults)
 	}
 
-	// Search through contents for matches
-	if opt.SearchContent && numMatches < opt.Limit
-  
-  {
+	// Search through contents for matches. IncludeGlobs/ExcludeGlobs are
+	// applied here in Go: git pathspecs OR together, so an include glob
+	// can't narrow a search that already lists files explicitly (every file
+	// still matches via its own literal pathspec entry regardless of the
+	// glob). Pre-filtering gives the AND semantics IncludeGlobs needs; the
+	// pathspec args grepFiles also sends only matter for ExcludeGlobs, whose
+	// exclude pathspecs subtract unconditionally.
+	if opt.SearchContent && numMatches < opt.Limit {
+		contentSearchFiles := filterByGlobs(filteredFiles, opt.IncludeGlobs, opt.ExcludeGlobs)
+
 		parser := newGrepParser(opt.Limit - numMatches)
-		lineResults, numMatchLines, err := opt.GrepFiles(ctx, repoDir, filteredFiles, query, opt.CaseSensitive, opt.Regex, opt.ContextLines, parser)
+		lineResults, numMatchLines, err := opt.Backend.GrepFiles(ctx, repoDir, "", contentSearchFiles, query, opt.CaseSensitive, opt.Regex, opt.IsFuzzy, opt.IncludeBinary, opt.ContextLines, opt.MatchesPerFile, opt.IncludeGlobs, opt.ExcludeGlobs, parser)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to execute search in dir %q: %v", repoDir, err)
 		}
@@ -158,6 +192,5 @@ func QuerySearch(ctx context.Context, repoDir, sha, query string, opt Options) (
 		numMatches = numMatches + numMatchLines
 	}
 
-	 return results, numMatches, nil
-  
+	return results, numMatches, nil
 }